@@ -15,31 +15,84 @@
 package meshconfig
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
 
 	"istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pkg/config/mesh"
-	"istio.io/pkg/filewatcher"
+	"istio.io/istio/pkg/util/gogoprotomarshal"
 	"istio.io/pkg/log"
 )
 
 var scope = log.RegisterScope("meshconfig", "meshconfig watcher/reader", 0)
 
+// MeshHandler is invoked whenever the cached mesh config changes. prev is the
+// previously cached value, curr is the newly installed value.
+type MeshHandler func(prev, curr v1alpha1.MeshConfig)
+
 // Cache is an interface for getting a cached copy of mesh.
 type Cache interface {
 	// Get returns the cached copy of mesh config.
 	Get() v1alpha1.MeshConfig
+
+	// GetSnapshot returns the current immutable Snapshot, which can be held
+	// and compared across calls without any locking.
+	GetSnapshot() *Snapshot
+
+	// AddMeshHandler registers a handler that is invoked whenever the cached
+	// mesh config changes. It returns an id that can be passed to
+	// RemoveMeshHandler to unregister the handler.
+	AddMeshHandler(h MeshHandler) int
+
+	// RemoveMeshHandler unregisters the handler previously registered with
+	// AddMeshHandler.
+	RemoveMeshHandler(id int)
 }
 
-// FsCache is a Cache implementation that reads mesh from file.
+// FsCache is a Cache implementation that reads mesh config from a stack of
+// layered Sources, of any backend (file, ConfigMap, HTTP, ...), and reloads
+// whenever any of them reports a change. The name predates pluggable
+// backends and is kept for compatibility.
 type FsCache struct {
-	path string
-	fw   filewatcher.FileWatcher
+	sourcesMutex sync.Mutex
+	sources      []Source
+
+	cancel context.CancelFunc
+
+	snapshot atomic.Pointer[Snapshot]
+
+	handlersMutex sync.Mutex
+	handlerIDs    []int
+	handlers      map[int]MeshHandler
+	nextHandlerID int
+
+	validatorsMutex sync.Mutex
+	validators      []Validator
 
-	cachedMutex sync.Mutex
-	cached      v1alpha1.MeshConfig
+	lastErrMutex sync.Mutex
+	lastErr      error
+
+	// lkgPath is the sibling file that the last successfully validated
+	// config is mirrored to, so a future startup can fall back to it. It is
+	// empty unless the layer stack includes a file source.
+	lkgPath string
+
+	notify chan meshChange
+	done   chan struct{}
+}
+
+// meshChange carries a single published transition to the dispatch goroutine.
+type meshChange struct {
+	prev v1alpha1.MeshConfig
+	curr v1alpha1.MeshConfig
 }
 
 var _ Cache = &FsCache{}
@@ -52,59 +105,226 @@ var DefaultMeshConfig = func() v1alpha1.MeshConfig {
 	return meshconfig
 }()
 
-// NewCacheFromFile returns a new mesh cache, based on watching a file.
+// NewCacheFromFile returns a new mesh cache, based on watching a single file.
+// It is a thin convenience wrapper around NewCache. If path fails to parse
+// or validate on startup, it transparently falls back to the last-known-good
+// copy left by a previous successful reload, if one exists, rather than
+// silently running with DefaultMeshConfig.
 func NewCacheFromFile(path string) (*FsCache, error) {
-	fw := filewatcher.NewWatcher()
-
-	err := fw.Add(path)
+	c, err := NewCache(FileSource(path))
 	if err != nil {
 		return nil, err
 	}
 
-	c := &FsCache{
-		path:   path,
-		fw:     fw,
-		cached: DefaultMeshConfig,
+	if c.LastError() != nil {
+		lkg := path + lastKnownGoodSuffix
+		if _, statErr := os.Stat(lkg); statErr == nil {
+			scope.Warnf("Mesh config at %s is invalid (%v); falling back to last-known-good copy %s", path, c.LastError(), lkg)
+			c.bootstrapFrom(context.Background(), FileSource(lkg))
+		}
 	}
 
-	c.reload()
+	return c, nil
+}
 
-	go func() {
-		for range fw.Events(path) {
-			c.reload()
-		}
-	}()
+// bootstrapFrom seeds the cache's initial snapshot from src, then restores
+// the original source stack. The cache keeps watching path (set up back in
+// NewLayeredCache), so once the real source is fixed the next reload picks
+// it back up on its own — the process does not get stuck permanently
+// serving the last-known-good copy. sources is swapped under sourcesMutex
+// so a concurrent watch-triggered reload never observes it mid-write.
+func (c *FsCache) bootstrapFrom(ctx context.Context, src Source) {
+	c.sourcesMutex.Lock()
+	original := c.sources
+	c.sources = []Source{src}
+	c.sourcesMutex.Unlock()
 
-	return c, nil
+	c.reload(ctx)
+
+	c.sourcesMutex.Lock()
+	c.sources = original
+	c.sourcesMutex.Unlock()
 }
 
 // Get returns the cached copy of mesh config.
 func (c *FsCache) Get() v1alpha1.MeshConfig {
-	c.cachedMutex.Lock()
-	defer c.cachedMutex.Unlock()
-	return c.cached
+	return *c.GetSnapshot().Config
 }
 
-func (c *FsCache) reload() {
-	by, err := ioutil.ReadFile(c.path)
-	if err != nil {
-		scope.Errorf("Error loading mesh config (path: %s): %v", c.path, err)
+// GetSnapshot returns the current immutable Snapshot. It never blocks and
+// never allocates beyond the atomic load itself, making it safe to call on
+// every xDS push without contending with reload().
+func (c *FsCache) GetSnapshot() *Snapshot {
+	return c.snapshot.Load()
+}
+
+// AddMeshHandler registers a handler that is invoked whenever the cached mesh
+// config changes, and returns an id that can be used to remove it later.
+func (c *FsCache) AddMeshHandler(h MeshHandler) int {
+	c.handlersMutex.Lock()
+	defer c.handlersMutex.Unlock()
+
+	id := c.nextHandlerID
+	c.nextHandlerID++
+	c.handlers[id] = h
+	c.handlerIDs = append(c.handlerIDs, id)
+	return id
+}
+
+// RemoveMeshHandler unregisters the handler previously registered with id.
+func (c *FsCache) RemoveMeshHandler(id int) {
+	c.handlersMutex.Lock()
+	defer c.handlersMutex.Unlock()
+
+	delete(c.handlers, id)
+	for i, hid := range c.handlerIDs {
+		if hid == id {
+			c.handlerIDs = append(c.handlerIDs[:i], c.handlerIDs[i+1:]...)
+			break
+		}
+	}
+}
+
+// dispatchLoop invokes registered handlers sequentially, in registration
+// order, for every published change. Running on a single goroutine guarantees
+// handlers observe changes in the order they occurred, and keeps handler
+// invocation off reload()'s goroutine.
+func (c *FsCache) dispatchLoop() {
+	for {
+		select {
+		case change := <-c.notify:
+			c.handlersMutex.Lock()
+			ids := append([]int(nil), c.handlerIDs...)
+			c.handlersMutex.Unlock()
+
+			for _, id := range ids {
+				c.handlersMutex.Lock()
+				h, ok := c.handlers[id]
+				c.handlersMutex.Unlock()
+				if ok {
+					h(change.prev, change.curr)
+				}
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// publish notifies the dispatch goroutine of a mesh config transition, if and
+// only if the configuration actually changed. It never blocks past Close:
+// if dispatchLoop has already exited and notify's buffer is full, selecting
+// on done lets the caller (reload, possibly running concurrently with
+// Close) return instead of leaking forever on a send nobody will receive.
+func (c *FsCache) publish(prev, curr v1alpha1.MeshConfig) {
+	if proto.Equal(&prev, &curr) {
 		return
 	}
+	select {
+	case c.notify <- meshChange{prev: prev, curr: curr}:
+	case <-c.done:
+	}
+}
 
-	cfg, err := mesh.ApplyMeshConfig(string(by), DefaultMeshConfig)
+// reload re-applies every source, in order, on top of DefaultMeshConfig,
+// runs the registered validators, and installs the result as the new cached
+// value. If loading, parsing, or validation fails, the previously cached
+// value is retained and the failure is recorded for LastError.
+func (c *FsCache) reload(ctx context.Context) {
+	c.sourcesMutex.Lock()
+	sources := append([]Source(nil), c.sources...)
+	c.sourcesMutex.Unlock()
+
+	merged := DefaultMeshConfig
+	for _, src := range sources {
+		by, _, err := src.Read(ctx)
+		if err != nil {
+			c.setLastError(fmt.Errorf("reading mesh config source %s: %v", src, err))
+			return
+		}
+
+		cfg, err := mesh.ApplyMeshConfig(string(by), merged)
+		if err != nil {
+			c.setLastError(fmt.Errorf("applying mesh config overlay from %s: %v", src, err))
+			return
+		}
+		merged = *cfg
+	}
+
+	if err := c.validate(&merged); err != nil {
+		c.setLastError(fmt.Errorf("mesh config failed validation: %v", err))
+		return
+	}
+
+	hash, err := hashConfig(&merged)
 	if err != nil {
-		scope.Errorf("Error reading mesh config as json: %v", err)
+		c.setLastError(fmt.Errorf("hashing mesh config: %v", err))
 		return
 	}
 
-	c.cachedMutex.Lock()
-	defer c.cachedMutex.Unlock()
-	c.cached = *cfg
-	scope.Infof("Reloaded mesh config: \n%s\n", string(by))
+	prevSnapshot := c.snapshot.Load()
+	c.setLastError(nil)
+
+	if prevSnapshot != nil && prevSnapshot.Hash == hash {
+		// Byte-for-byte identical to what's already cached (e.g. a
+		// kubectl apply that rewrote the file with the same content):
+		// skip the revision bump and handler dispatch entirely.
+		return
+	}
+
+	var prevConfig v1alpha1.MeshConfig
+	revision := uint64(1)
+	if prevSnapshot != nil {
+		prevConfig = *prevSnapshot.Config
+		revision = prevSnapshot.Revision + 1
+	}
+
+	next := &Snapshot{
+		Config:   &merged,
+		Revision: revision,
+		LoadedAt: time.Now(),
+		Hash:     hash,
+	}
+	c.snapshot.Store(next)
+
+	if c.lkgPath != "" {
+		if err := c.writeLastKnownGood(&merged); err != nil {
+			scope.Errorf("Error writing last-known-good mesh config to %s: %v", c.lkgPath, err)
+		}
+	}
+
+	scope.Infof("Reloaded mesh config from %d source(s), revision %d", len(sources), revision)
+	c.publish(prevConfig, merged)
+}
+
+// setLastError records the outcome of the most recent reload attempt.
+func (c *FsCache) setLastError(err error) {
+	c.lastErrMutex.Lock()
+	defer c.lastErrMutex.Unlock()
+	c.lastErr = err
+}
+
+// LastError returns the error from the most recent reload attempt, or nil if
+// it succeeded. A non-nil error means the cached config is still the last
+// value that loaded and validated successfully, or DefaultMeshConfig if no
+// reload has succeeded yet.
+func (c *FsCache) LastError() error {
+	c.lastErrMutex.Lock()
+	defer c.lastErrMutex.Unlock()
+	return c.lastErr
+}
+
+func (c *FsCache) writeLastKnownGood(cfg *v1alpha1.MeshConfig) error {
+	by, err := gogoprotomarshal.ToYAML(cfg)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.lkgPath, []byte(by), 0o644)
 }
 
-// Close closes this cache.
+// Close closes this cache, stopping any sources it is watching.
 func (c *FsCache) Close() error {
-	return c.fw.Close()
+	close(c.done)
+	c.cancel()
+	return nil
 }
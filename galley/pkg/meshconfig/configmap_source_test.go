@@ -0,0 +1,105 @@
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package meshconfig
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigMapSourceRead(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "istio",
+			Namespace:       "istio-system",
+			ResourceVersion: "1",
+		},
+		Data: map[string]string{
+			"mesh": `{"ingressClass":"istio"}`,
+		},
+	})
+
+	src := ConfigMapSource(client, "istio-system", "istio", "mesh")
+
+	data, etag, err := src.Read(context.Background())
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if string(data) != `{"ingressClass":"istio"}` {
+		t.Fatalf("unexpected data: %s", data)
+	}
+	if etag != "1" {
+		t.Fatalf("expected etag to be the ConfigMap's resourceVersion %q, got %q", "1", etag)
+	}
+}
+
+func TestConfigMapSourceReadMissingKey(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio", Namespace: "istio-system"},
+		Data:       map[string]string{},
+	})
+
+	src := ConfigMapSource(client, "istio-system", "istio", "mesh")
+	if _, _, err := src.Read(context.Background()); err == nil {
+		t.Fatalf("expected an error reading a missing key, got nil")
+	}
+}
+
+func TestConfigMapSourceWatchSkipsUnchangedResourceVersion(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "istio",
+			Namespace:       "istio-system",
+			ResourceVersion: "1",
+		},
+		Data: map[string]string{"mesh": `{"ingressClass":"istio"}`},
+	})
+
+	src := &configMapSource{client: client, namespace: "istio-system", name: "istio", key: "mesh", pollInterval: 10 * time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := src.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	select {
+	case <-events:
+		t.Fatalf("expected no Event while the ConfigMap's ResourceVersion is unchanged")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("istio-system").Get(context.Background(), "istio", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	cm.ResourceVersion = "2"
+	if _, err := client.CoreV1().ConfigMaps("istio-system").Update(context.Background(), cm, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	select {
+	case <-events:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected an Event once the ResourceVersion changed")
+	}
+}
@@ -0,0 +1,139 @@
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package meshconfig
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultHTTPPollInterval is used when the server's response carries no
+// Cache-Control: max-age directive.
+const defaultHTTPPollInterval = 30 * time.Second
+
+// httpSource polls an HTTP(S) endpoint for its overlay, using conditional
+// requests so an unchanged upstream costs a 304 rather than a full body.
+type httpSource struct {
+	url    string
+	client *http.Client
+
+	mu           sync.Mutex
+	etag         string
+	lastData     []byte
+	pollInterval time.Duration
+}
+
+// HTTPSource returns a Source that fetches its overlay over HTTP(S). The
+// poll interval starts at defaultHTTPPollInterval and is subsequently driven
+// by the response's Cache-Control: max-age, if present.
+func HTTPSource(url string) Source {
+	return &httpSource{url: url, client: http.DefaultClient, pollInterval: defaultHTTPPollInterval}
+}
+
+func (h *httpSource) String() string { return fmt.Sprintf("http:%s", h.url) }
+
+func (h *httpSource) Read(ctx context.Context) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	h.mu.Lock()
+	if h.etag != "" {
+		req.Header.Set("If-None-Match", h.etag)
+	}
+	h.mu.Unlock()
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return h.lastData, h.etag, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetching %s: unexpected status %s", h.url, resp.Status)
+	}
+
+	by, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		etag = etagOf(by)
+	}
+	h.etag = etag
+	h.lastData = by
+
+	if maxAge, ok := parseMaxAge(resp.Header.Get("Cache-Control")); ok && maxAge > 0 {
+		h.pollInterval = maxAge
+	}
+
+	return by, etag, nil
+}
+
+// Watch polls at the interval learned from the most recent Read, re-checking
+// after every tick in case a fresh Cache-Control: max-age has changed it.
+func (h *httpSource) Watch(ctx context.Context) (<-chan Event, error) {
+	out := make(chan Event, 1)
+	go func() {
+		defer close(out)
+		for {
+			h.mu.Lock()
+			interval := h.pollInterval
+			h.mu.Unlock()
+
+			select {
+			case <-time.After(interval):
+				select {
+				case out <- Event{}:
+				default:
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// parseMaxAge extracts max-age from a Cache-Control header value.
+func parseMaxAge(cacheControl string) (time.Duration, bool) {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.TrimSpace(directive)
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+		secs, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+		if err != nil {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	return 0, false
+}
@@ -0,0 +1,78 @@
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package meshconfig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSourceReadFetchesBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "max-age=5")
+		w.Write([]byte(`{"ingressClass":"istio"}`))
+	}))
+	defer srv.Close()
+
+	src := HTTPSource(srv.URL)
+
+	data, etag, err := src.Read(context.Background())
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if string(data) != `{"ingressClass":"istio"}` {
+		t.Fatalf("unexpected data: %s", data)
+	}
+	if etag != `"v1"` {
+		t.Fatalf("expected etag to be the response's ETag header, got %q", etag)
+	}
+}
+
+func TestHTTPSourceReadReusesLastDataOn304(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"ingressClass":"istio"}`))
+	}))
+	defer srv.Close()
+
+	src := HTTPSource(srv.URL)
+
+	if _, _, err := src.Read(context.Background()); err != nil {
+		t.Fatalf("first Read: %v", err)
+	}
+
+	data, etag, err := src.Read(context.Background())
+	if err != nil {
+		t.Fatalf("second Read: %v", err)
+	}
+	if requests != 2 {
+		t.Fatalf("expected the second Read to issue a conditional request, got %d total requests", requests)
+	}
+	if string(data) != `{"ingressClass":"istio"}` {
+		t.Fatalf("expected a 304 to reuse the previously fetched body, got: %s", data)
+	}
+	if etag != `"v1"` {
+		t.Fatalf("expected etag to be retained across a 304, got %q", etag)
+	}
+}
@@ -0,0 +1,52 @@
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package meshconfig
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pkg/util/gogoprotomarshal"
+)
+
+// Snapshot is an immutable, point-in-time view of the cached mesh config.
+// Readers on the xDS generation hot path can hold a Snapshot without any
+// locking, since a new reload never mutates one in place - it installs a
+// new Snapshot instead.
+type Snapshot struct {
+	Config   *v1alpha1.MeshConfig
+	Revision uint64
+	LoadedAt time.Time
+	Hash     string
+}
+
+// hashConfig returns a stable hash of cfg, used to detect reloads that
+// produced logically identical config (common when a ConfigMap is rewritten
+// with unchanged content). Plain proto.Marshal is not used here because gogo
+// does not sort map-typed fields (e.g. DefaultConfig.ProxyMetadata), so two
+// semantically identical configs could marshal to different bytes and defeat
+// the dedup this hash exists for. gogoprotomarshal.ToYAML goes through
+// encoding/json internally, which does sort map keys, giving a canonical
+// encoding to hash instead.
+func hashConfig(cfg *v1alpha1.MeshConfig) (string, error) {
+	canonical, err := gogoprotomarshal.ToYAML(cfg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return fmt.Sprintf("%x", sum), nil
+}
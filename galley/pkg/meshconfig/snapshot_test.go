@@ -0,0 +1,63 @@
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package meshconfig
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReloadSkipsRevisionBumpWhenUnchanged(t *testing.T) {
+	c, err := NewLayeredCache(LiteralSource("base", `{"ingressClass":"istio"}`))
+	if err != nil {
+		t.Fatalf("NewLayeredCache: %v", err)
+	}
+	defer c.Close()
+
+	before := c.GetSnapshot()
+
+	// Re-reading byte-for-byte identical content must not bump the
+	// revision, even though reload() ran again.
+	c.reload(context.Background())
+
+	after := c.GetSnapshot()
+	if after.Revision != before.Revision {
+		t.Fatalf("expected revision to stay at %d for an unchanged reload, got %d", before.Revision, after.Revision)
+	}
+	if after != before {
+		t.Fatalf("expected the exact same Snapshot to still be installed when content is unchanged")
+	}
+}
+
+func TestReloadBumpsRevisionWhenChanged(t *testing.T) {
+	c, err := NewLayeredCache(LiteralSource("base", `{"ingressClass":"istio"}`))
+	if err != nil {
+		t.Fatalf("NewLayeredCache: %v", err)
+	}
+	defer c.Close()
+
+	before := c.GetSnapshot()
+
+	c.sources = []Source{LiteralSource("base", `{"ingressClass":"other"}`)}
+	c.reload(context.Background())
+
+	after := c.GetSnapshot()
+	if after.Revision != before.Revision+1 {
+		t.Fatalf("expected revision %d after a real change, got %d", before.Revision+1, after.Revision)
+	}
+	if after.Config.IngressClass != "other" {
+		t.Fatalf("expected new config to be installed, got IngressClass=%q", after.Config.IngressClass)
+	}
+}
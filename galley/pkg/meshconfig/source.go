@@ -0,0 +1,346 @@
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package meshconfig
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pkg/util/gogoprotomarshal"
+	"istio.io/pkg/filewatcher"
+)
+
+// reloadDebounce is the quiet period applied after a burst of source events
+// before a layered cache is reloaded. A single kubectl apply against a
+// projected ConfigMap volume often produces several rapid events for the
+// same logical change.
+const reloadDebounce = 100 * time.Millisecond
+
+// Event signals that a Source's content may have changed. It carries no
+// payload; the cache reacts by calling Read again, so that the reload
+// pipeline (apply defaults, parse, validate, publish) is identical no matter
+// what triggered it.
+type Event struct{}
+
+// Source is a single named contributor to a mesh config cache. A cache may
+// be backed by exactly one Source (NewCache) or by several layered on top of
+// each other (NewLayeredCache); in the latter case each Source's Read output
+// is treated as a YAML/JSON overlay applied on top of the sources before it
+// via mesh.ApplyMeshConfig. A field a source's overlay omits keeps whatever
+// value an earlier source (or DefaultMeshConfig) gave it; a field it sets,
+// scalar or repeated, replaces the earlier value wholesale rather than
+// merging into it - e.g. an overlay's extensionProviders list replaces the
+// previous layer's list rather than appending to it. There is no separate,
+// per-field-configurable merge strategy: every layer, and every DirSource
+// fragment within a layer, follows this same replace-on-set rule.
+type Source interface {
+	// Read returns the source's current raw content, plus an opaque etag
+	// that changes whenever the content has changed. An etag may also change
+	// when the content has not (a ConfigMap's resourceVersion moves when any
+	// of its keys is edited). Implementations that cannot cheaply compute an
+	// etag may return the content's hash.
+	Read(ctx context.Context) (data []byte, etag string, err error)
+
+	// Watch returns a channel that receives an Event whenever the source's
+	// content may have changed. The channel, and anything Watch started, is
+	// torn down when ctx is cancelled. Sources with no way to observe
+	// changes (e.g. a literal) may return a nil channel.
+	Watch(ctx context.Context) (<-chan Event, error)
+
+	fmt.Stringer
+}
+
+func etagOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("%x", sum)
+}
+
+// fileSource reads a single overlay file from disk.
+type fileSource struct {
+	path string
+}
+
+// FileSource returns a Source backed by a single file on disk.
+func FileSource(path string) Source {
+	return &fileSource{path: path}
+}
+
+func (f *fileSource) Read(_ context.Context) ([]byte, string, error) {
+	by, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, "", err
+	}
+	return by, etagOf(by), nil
+}
+
+func (f *fileSource) Watch(ctx context.Context) (<-chan Event, error) {
+	return watchPaths(ctx, f.path)
+}
+
+func (f *fileSource) String() string { return fmt.Sprintf("file:%s", f.path) }
+
+// dirSource merges every fragment file in a directory, in lexicographic
+// filename order, into a single overlay.
+type dirSource struct {
+	dir string
+}
+
+// DirSource returns a Source that folds every regular file in dir, in
+// lexicographic order, into a single resolved overlay: each fragment is
+// unmarshaled on top of the ones before it, so a later fragment overrides an
+// earlier one field-by-field instead of the two being
+// concatenated into one YAML document with duplicate mapping keys. This lets
+// an operator ship one fragment per ConfigMap key and have them apply as
+// successive overrides of each other. The fold starts from a zero-value
+// MeshConfig, not DefaultMeshConfig, and the result is marshaled back to
+// YAML with defaults omitted, so the emitted overlay only mentions fields a
+// fragment actually set; when DirSource is layered after another source in
+// NewLayeredCache, that keeps it from resetting the earlier source's fields
+// back to their defaults.
+func DirSource(dir string) Source {
+	return &dirSource{dir: dir}
+}
+
+func (d *dirSource) Read(_ context.Context) ([]byte, string, error) {
+	entries, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	var folded v1alpha1.MeshConfig
+	for _, name := range names {
+		by, err := ioutil.ReadFile(filepath.Join(d.dir, name))
+		if err != nil {
+			return nil, "", err
+		}
+
+		if err := gogoprotomarshal.ApplyYAML(string(by), &folded); err != nil {
+			return nil, "", fmt.Errorf("applying fragment %s: %v", name, err)
+		}
+	}
+
+	out, err := gogoprotomarshal.ToYAML(&folded)
+	if err != nil {
+		return nil, "", err
+	}
+	merged := []byte(out)
+	return merged, etagOf(merged), nil
+}
+
+func (d *dirSource) Watch(ctx context.Context) (<-chan Event, error) {
+	return watchPaths(ctx, d.dir)
+}
+
+func (d *dirSource) String() string { return fmt.Sprintf("dir:%s", d.dir) }
+
+// watchPaths watches one or more filesystem paths with a fresh FileWatcher
+// and relays every event on any of them as an Event, until ctx is cancelled.
+func watchPaths(ctx context.Context, paths ...string) (<-chan Event, error) {
+	fw := filewatcher.NewWatcher()
+	for _, p := range paths {
+		if err := fw.Add(p); err != nil {
+			_ = fw.Close()
+			return nil, err
+		}
+	}
+
+	out := make(chan Event, 1)
+	go func() {
+		defer close(out)
+		defer fw.Close()
+
+		cases := make(chan string, len(paths))
+		for _, p := range paths {
+			p := p
+			go func() {
+				for {
+					select {
+					case _, ok := <-fw.Events(p):
+						if !ok {
+							return
+						}
+						select {
+						case cases <- p:
+						case <-ctx.Done():
+							return
+						}
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+
+		for {
+			select {
+			case <-cases:
+				select {
+				case out <- Event{}:
+				default:
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// literalSource is an in-memory overlay, useful for tests and for values
+// computed by the caller rather than read from disk.
+type literalSource struct {
+	name string
+	yaml string
+}
+
+// LiteralSource returns a Source backed by an in-memory YAML/JSON string.
+// name is used only for logging. Its content never changes, so Watch never
+// emits an Event.
+func LiteralSource(name, yaml string) Source {
+	return &literalSource{name: name, yaml: yaml}
+}
+
+func (l *literalSource) Read(_ context.Context) ([]byte, string, error) {
+	by := []byte(l.yaml)
+	return by, etagOf(by), nil
+}
+
+func (l *literalSource) Watch(_ context.Context) (<-chan Event, error) { return nil, nil }
+func (l *literalSource) String() string                                { return fmt.Sprintf("literal:%s", l.name) }
+
+// NewLayeredCache returns a new mesh Cache that computes its value by
+// applying sources, in order, on top of DefaultMeshConfig. Later sources
+// override fields set by earlier ones, following the same repeated-field
+// merge semantics as mesh.ApplyMeshConfig. An Event from any source triggers
+// a single debounced reload of the whole stack.
+func NewLayeredCache(sources ...Source) (*FsCache, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &FsCache{
+		sources:  sources,
+		cancel:   cancel,
+		handlers: make(map[int]MeshHandler),
+		notify:   make(chan meshChange, 10),
+		done:     make(chan struct{}),
+	}
+
+	defaultConfig := DefaultMeshConfig
+	defaultHash, err := hashConfig(&defaultConfig)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("hashing default mesh config: %v", err)
+	}
+	c.snapshot.Store(&Snapshot{Config: &defaultConfig, Revision: 0, Hash: defaultHash})
+
+	for _, src := range sources {
+		if fs, ok := src.(*fileSource); ok {
+			c.lkgPath = fs.path + lastKnownGoodSuffix
+		}
+	}
+
+	go c.dispatchLoop()
+
+	c.reload(ctx)
+
+	events := make(chan Event, 1)
+	for _, src := range sources {
+		ch, err := src.Watch(ctx)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		if ch == nil {
+			continue
+		}
+		go forwardEvents(ch, events, ctx.Done())
+	}
+	go c.debounceReload(ctx, events)
+
+	return c, nil
+}
+
+// NewCache returns a new mesh Cache backed by a single Source. It is the
+// primary entry point for pluggable backends and is NewLayeredCache with a
+// single source; NewCacheFromFile is a convenience wrapper around it.
+func NewCache(src Source) (*FsCache, error) {
+	return NewLayeredCache(src)
+}
+
+// forwardEvents relays every Event on in to out, without blocking, until
+// done is closed.
+func forwardEvents(in <-chan Event, out chan Event, done <-chan struct{}) {
+	for {
+		select {
+		case _, ok := <-in:
+			if !ok {
+				return
+			}
+			select {
+			case out <- Event{}:
+			default:
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// debounceReload collapses a burst of source events into a single reload,
+// fired reloadDebounce after the last observed event.
+func (c *FsCache) debounceReload(ctx context.Context, events chan Event) {
+	var timer *time.Timer
+	for {
+		select {
+		case <-events:
+			if timer == nil {
+				timer = time.NewTimer(reloadDebounce)
+			} else {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(reloadDebounce)
+			}
+		case <-timerC(timer):
+			timer = nil
+			c.reload(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// timerC returns t.C, or a nil channel (which blocks forever) if t is nil,
+// so debounceReload's select is safe before the first event arrives.
+func timerC(t *time.Timer) <-chan time.Time {
+	if t == nil {
+		return nil
+	}
+	return t.C
+}
@@ -0,0 +1,89 @@
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package meshconfig
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"istio.io/istio/pkg/config/mesh"
+)
+
+func TestDirSourceLaterFragmentOverridesEarlier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirsource")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "01-base.yaml"), []byte(`ingressClass: istio`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "02-override.yaml"), []byte(`ingressClass: custom`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	src := DirSource(dir)
+	data, _, err := src.Read(context.Background())
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	cfg, err := mesh.ApplyMeshConfig(string(data), DefaultMeshConfig)
+	if err != nil {
+		t.Fatalf("ApplyMeshConfig on merged overlay: %v", err)
+	}
+
+	if cfg.IngressClass != "custom" {
+		t.Fatalf("expected the lexicographically later fragment to win, got IngressClass=%q", cfg.IngressClass)
+	}
+}
+
+// TestDirSourceOverlayIsSparse guards against DirSource.Read emitting a full
+// document (defaults included) that would clobber fields set by an earlier
+// source in a layered stack, rather than a sparse overlay containing only
+// what its fragments actually set.
+func TestDirSourceOverlayIsSparse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirsource")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "01-ingress.yaml"), []byte(`ingressClass: custom`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	// trustDomain defaults to "cluster.local" in DefaultMeshConfig; setting it
+	// to something else here means a fold that starts back at
+	// DefaultMeshConfig (instead of a zero-value config) would silently reset
+	// it, since the DirSource fragment above never mentions trustDomain.
+	c, err := NewLayeredCache(LiteralSource("base", `{"trustDomain":"custom.example.com"}`), DirSource(dir))
+	if err != nil {
+		t.Fatalf("NewLayeredCache: %v", err)
+	}
+	defer c.Close()
+
+	got := c.Get()
+	if got.IngressClass != "custom" {
+		t.Fatalf("expected DirSource's fragment to set IngressClass, got %q", got.IngressClass)
+	}
+	if got.TrustDomain != "custom.example.com" {
+		t.Fatalf("expected the earlier source's TrustDomain to survive layering DirSource on top, got %q", got.TrustDomain)
+	}
+}
@@ -0,0 +1,97 @@
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package meshconfig
+
+import (
+	"fmt"
+
+	"istio.io/api/mesh/v1alpha1"
+)
+
+// lastKnownGoodSuffix is appended to a file source's path to form the
+// sibling file that the last successfully validated config is mirrored to.
+const lastKnownGoodSuffix = ".last-known-good"
+
+// Validator performs a semantic check on a candidate mesh config, beyond
+// what mesh.ApplyMeshConfig itself enforces. A non-nil error fails the
+// reload that produced cfg, leaving the previously cached value in place.
+type Validator func(cfg *v1alpha1.MeshConfig) error
+
+// AddValidator registers a Validator that every subsequent reload must pass
+// before it is installed as the cached value.
+func (c *FsCache) AddValidator(v Validator) {
+	c.validatorsMutex.Lock()
+	defer c.validatorsMutex.Unlock()
+	c.validators = append(c.validators, v)
+}
+
+// validate runs every registered validator against cfg, in registration
+// order, stopping at the first failure.
+func (c *FsCache) validate(cfg *v1alpha1.MeshConfig) error {
+	c.validatorsMutex.Lock()
+	validators := append([]Validator(nil), c.validators...)
+	c.validatorsMutex.Unlock()
+
+	for _, v := range validators {
+		if err := v(cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateExtensionProviderNamesUnique is a Validator that rejects a mesh
+// config whose extensionProviders entries reuse a name, since consumers
+// (telemetry, tracing) look providers up by name.
+func ValidateExtensionProviderNamesUnique(cfg *v1alpha1.MeshConfig) error {
+	seen := make(map[string]bool, len(cfg.ExtensionProviders))
+	for _, p := range cfg.ExtensionProviders {
+		if seen[p.Name] {
+			return fmt.Errorf("duplicate extension provider name %q", p.Name)
+		}
+		seen[p.Name] = true
+	}
+	return nil
+}
+
+// ValidateTrustDomainAliases is a Validator that rejects a mesh config with
+// an empty or duplicate trust domain alias, since they are used as a set
+// when matching peer identities.
+func ValidateTrustDomainAliases(cfg *v1alpha1.MeshConfig) error {
+	seen := make(map[string]bool, len(cfg.TrustDomainAliases))
+	for _, alias := range cfg.TrustDomainAliases {
+		if alias == "" {
+			return fmt.Errorf("trustDomainAliases contains an empty entry")
+		}
+		if seen[alias] {
+			return fmt.Errorf("duplicate trustDomainAlias %q", alias)
+		}
+		seen[alias] = true
+	}
+	return nil
+}
+
+// ValidateOutboundTrafficPolicyWithMTLS is a Validator that rejects a mesh
+// config that combines an ALLOW_ANY outbound traffic policy with automatic
+// mTLS disabled, since that combination silently permits plaintext egress.
+func ValidateOutboundTrafficPolicyWithMTLS(cfg *v1alpha1.MeshConfig) error {
+	if cfg.OutboundTrafficPolicy == nil || cfg.EnableAutoMtls == nil {
+		return nil
+	}
+	if cfg.OutboundTrafficPolicy.Mode == v1alpha1.MeshConfig_OutboundTrafficPolicy_ALLOW_ANY && !cfg.EnableAutoMtls.Value {
+		return fmt.Errorf("outboundTrafficPolicy ALLOW_ANY requires enableAutoMtls to be true")
+	}
+	return nil
+}
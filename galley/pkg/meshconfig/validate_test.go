@@ -0,0 +1,93 @@
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package meshconfig
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pkg/util/gogoprotomarshal"
+)
+
+func TestValidatorRejectionKeepsPreviousConfig(t *testing.T) {
+	c, err := NewLayeredCache(LiteralSource("base", `{"ingressClass":"istio"}`))
+	if err != nil {
+		t.Fatalf("NewLayeredCache: %v", err)
+	}
+	defer c.Close()
+
+	c.AddValidator(func(cfg *v1alpha1.MeshConfig) error {
+		if cfg.IngressClass == "bad" {
+			return fmt.Errorf("ingressClass must not be %q", "bad")
+		}
+		return nil
+	})
+
+	before := c.Get()
+
+	c.sources = []Source{LiteralSource("base", `{"ingressClass":"bad"}`)}
+	c.reload(context.Background())
+
+	if c.LastError() == nil {
+		t.Fatalf("expected reload to fail validation")
+	}
+	if got := c.Get(); got.IngressClass != before.IngressClass {
+		t.Fatalf("expected the previous config to be retained after a validation failure, got IngressClass=%q", got.IngressClass)
+	}
+}
+
+func TestNewCacheFromFileFallsBackToLastKnownGood(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mesh.yaml")
+	lkg := path + lastKnownGoodSuffix
+
+	good := DefaultMeshConfig
+	good.IngressClass = "good"
+	by, err := gogoprotomarshal.ToYAML(&good)
+	if err != nil {
+		t.Fatalf("ToYAML: %v", err)
+	}
+	if err := ioutil.WriteFile(lkg, []byte(by), 0o644); err != nil {
+		t.Fatalf("WriteFile lkg: %v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte("not: [valid: yaml"), 0o644); err != nil {
+		t.Fatalf("WriteFile path: %v", err)
+	}
+
+	c, err := NewCacheFromFile(path)
+	if err != nil {
+		t.Fatalf("NewCacheFromFile: %v", err)
+	}
+	defer c.Close()
+
+	if got := c.Get(); got.IngressClass != "good" {
+		t.Fatalf("expected startup to fall back to the last-known-good copy, got IngressClass=%q", got.IngressClass)
+	}
+
+	// The cache must still be watching path itself (not stuck permanently
+	// on the last-known-good copy), so fixing it is picked up.
+	if err := ioutil.WriteFile(path, []byte("ingressClass: fixed"), 0o644); err != nil {
+		t.Fatalf("WriteFile fixed path: %v", err)
+	}
+	c.reload(context.Background())
+
+	if got := c.Get(); got.IngressClass != "fixed" {
+		t.Fatalf("expected recovery once the primary source is fixed, got IngressClass=%q", got.IngressClass)
+	}
+}